@@ -0,0 +1,60 @@
+// Package cache provides a thin, storage-agnostic caching abstraction used
+// throughout ottoman. It defines the contract a cache backend must satisfy
+// (WriteReader), a way to report its behaviour (Metric) and a couple of
+// small helpers (Normalize, Resolver) shared by the concrete backends.
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errUnknownCache is returned for a negatively-cached lookup, matching the
+// error backends return for a key they have never heard of.
+var errUnknownCache = errors.New("unknown cache")
+
+// WriteReader is the contract every cache backend (redis, in-process LRU,
+// layered, ...) must implement. Name identifies the backend for metrics and
+// logging purposes.
+type WriteReader interface {
+	Name() string
+	Write(key string, value []byte, expiration time.Duration) error
+	Read(key string) ([]byte, error)
+	ReadMulti(keys []string) (map[string][]byte, error)
+}
+
+// Metric is implemented by callers that want visibility into cache
+// behaviour, typically backed by Prometheus. Implementations must be safe
+// for concurrent use.
+type Metric interface {
+	CacheLatency(name, action string, n time.Duration)
+
+	// CacheHit and CacheMiss let a multi-layer backend (see Layered) report
+	// which layer served, or failed to serve, a given read.
+	CacheHit(name, layer string)
+	CacheMiss(name, layer string)
+}
+
+// Resolver turns a cache key into the *http.Request used to populate it from
+// a remote origin on miss.
+type Resolver interface {
+	Resolve(key string, r *http.Request) (*http.Request, error)
+	ResolveRequest(r *http.Request) (*http.Request, error)
+}
+
+// Normalize strips any existing "prefix:" from key and, if prefix is
+// non-empty, re-applies it. Passing an empty prefix therefore yields the bare
+// key regardless of what it was previously namespaced with.
+func Normalize(key, prefix string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		key = key[i+1:]
+	}
+
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + ":" + key
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"time"
+)
+
+const (
+	layerLocal  = "L1"
+	layerRemote = "L2"
+)
+
+// Publisher broadcasts key invalidations to other nodes sharing a cache
+// name. A nil Publisher makes Layered.Invalidate purely local.
+type Publisher interface {
+	Publish(channel string, keys ...string) error
+}
+
+// LayeredOption configures a Layered cache.
+type LayeredOption struct {
+	// Size bounds the number of entries kept in the local LRU. It is
+	// required; an unbounded local cache would grow with the remote's key
+	// space.
+	Size int
+
+	// TTL bounds how long a positive entry may live in the local LRU,
+	// regardless of the expiration it was written with.
+	TTL time.Duration
+
+	// NegativeTTL, when non-zero, opts in to caching "not found" results in
+	// the local LRU for this long. It defaults to disabled, since a cached
+	// negative lookup can hide a write that just landed on the remote
+	// layer.
+	NegativeTTL time.Duration
+
+	Metric    Metric
+	Publisher Publisher
+}
+
+// Layered is a WriteReader that fronts a slower remote WriteReader with a
+// bounded, in-process LRU, mirroring the supplier pattern used by
+// Mattermost's layered cache (PLT-5308): reads are served from the local
+// layer first and fall through to remote on miss, repopulating the local
+// layer as they go.
+type Layered struct {
+	name   string
+	remote WriteReader
+	local  *lru
+	opt    LayeredOption
+}
+
+// NewLayered returns a Layered cache named name, backed by remote and fronted
+// by an LRU configured by opt.
+func NewLayered(name string, remote WriteReader, opt LayeredOption) *Layered {
+	return &Layered{
+		name:   name,
+		remote: remote,
+		local:  newLRU(opt.Size, opt.TTL),
+		opt:    opt,
+	}
+}
+
+// Name identifies this backend for metrics and logging.
+func (c *Layered) Name() string {
+	return c.name
+}
+
+// Write updates both the local LRU and the remote layer.
+func (c *Layered) Write(key string, value []byte, expiration time.Duration) error {
+	if err := c.remote.Write(key, value, expiration); err != nil {
+		return err
+	}
+
+	c.local.Set(key, value, false)
+
+	return nil
+}
+
+// Read returns the value stored under key, consulting the local LRU before
+// falling through to the remote layer on miss.
+func (c *Layered) Read(key string) ([]byte, error) {
+	if v, miss, ok := c.local.Get(key); ok {
+		c.hit(layerLocal)
+
+		if miss {
+			return nil, errUnknownCache
+		}
+
+		return v, nil
+	}
+
+	c.miss(layerLocal)
+
+	v, err := c.remote.Read(key)
+	if err != nil {
+		c.miss(layerRemote)
+
+		if c.opt.NegativeTTL > 0 {
+			c.local.SetNegative(key, c.opt.NegativeTTL)
+		}
+
+		return nil, err
+	}
+
+	c.hit(layerRemote)
+	c.local.Set(key, v, false)
+
+	return v, nil
+}
+
+// ReadMulti returns the values stored under keys. Keys served from the
+// local LRU are removed from the set forwarded to the remote layer; the
+// result keeps the same shape ReadMulti backends already return: keys with
+// no value are absent rather than present with a nil value.
+func (c *Layered) ReadMulti(keys []string) (map[string][]byte, error) {
+	z := make(map[string][]byte, len(keys))
+
+	var missing []string
+
+	for _, key := range keys {
+		if v, miss, ok := c.local.Get(key); ok {
+			c.hit(layerLocal)
+
+			if !miss {
+				z[key] = v
+			}
+
+			continue
+		}
+
+		c.miss(layerLocal)
+		missing = append(missing, key)
+	}
+
+	if len(missing) == 0 {
+		return z, nil
+	}
+
+	rz, err := c.remote.ReadMulti(missing)
+	if err != nil {
+		c.miss(layerRemote)
+		return nil, err
+	}
+
+	for _, key := range missing {
+		v := rz[key]
+		if len(v) == 0 {
+			if c.opt.NegativeTTL > 0 {
+				c.local.SetNegative(key, c.opt.NegativeTTL)
+			}
+
+			continue
+		}
+
+		c.hit(layerRemote)
+		c.local.Set(key, v, false)
+		z[key] = v
+	}
+
+	return z, nil
+}
+
+// Invalidate evicts keys from the local LRU and, when a Publisher is
+// configured, broadcasts the invalidation so peer nodes evict them too.
+func (c *Layered) Invalidate(keys ...string) error {
+	for _, key := range keys {
+		c.local.Del(key)
+	}
+
+	if c.opt.Publisher == nil {
+		return nil
+	}
+
+	return c.opt.Publisher.Publish(c.name, keys...)
+}
+
+func (c *Layered) hit(layer string) {
+	if c.opt.Metric != nil {
+		c.opt.Metric.CacheHit(c.name, layer)
+	}
+}
+
+func (c *Layered) miss(layer string) {
+	if c.opt.Metric != nil {
+		c.opt.Metric.CacheMiss(c.name, layer)
+	}
+}
@@ -0,0 +1,194 @@
+package cache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bukalapak/ottoman/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// CountingReader wraps a Sample and counts how many times Read hits the
+// remote layer, so tests can assert on L1 vs L2 behaviour. ReadMulti goes
+// straight to the embedded Sample and is not counted.
+type CountingReader struct {
+	*Sample
+	mu    sync.Mutex
+	reads int
+}
+
+func NewCountingReader() *CountingReader {
+	return &CountingReader{Sample: NewReader().(*Sample)}
+}
+
+func (m *CountingReader) Read(key string) ([]byte, error) {
+	m.mu.Lock()
+	m.reads++
+	m.mu.Unlock()
+
+	return m.Sample.Read(key)
+}
+
+func (m *CountingReader) Reads() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.reads
+}
+
+type LayeredMetric struct {
+	mu        sync.Mutex
+	hits      map[string]int
+	miss      map[string]int
+	latencies int
+}
+
+func NewLayeredMetric() *LayeredMetric {
+	return &LayeredMetric{hits: make(map[string]int), miss: make(map[string]int)}
+}
+
+func (m *LayeredMetric) CacheLatency(name, action string, n time.Duration) {
+	m.mu.Lock()
+	m.latencies++
+	m.mu.Unlock()
+}
+
+func (m *LayeredMetric) CacheHit(name, layer string) {
+	m.mu.Lock()
+	m.hits[layer]++
+	m.mu.Unlock()
+}
+
+func (m *LayeredMetric) CacheMiss(name, layer string) {
+	m.mu.Lock()
+	m.miss[layer]++
+	m.mu.Unlock()
+}
+
+func TestLayered_Read(t *testing.T) {
+	remote := NewCountingReader()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8})
+
+	b, err := c.Read("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`{"foo":"bar"}`), b)
+	assert.Equal(t, 1, remote.Reads())
+
+	b, err = c.Read("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`{"foo":"bar"}`), b)
+	assert.Equal(t, 1, remote.Reads(), "second read should be served from L1")
+}
+
+func TestLayered_Read_unknownCache(t *testing.T) {
+	remote := NewCountingReader()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8})
+
+	b, err := c.Read("boo")
+	assert.NotNil(t, err)
+	assert.Nil(t, b)
+}
+
+func TestLayered_Read_negativeNotCachedByDefault(t *testing.T) {
+	remote := NewCountingReader()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8})
+
+	_, err := c.Read("boo")
+	assert.NotNil(t, err)
+
+	_, err = c.Read("boo")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, remote.Reads(), "without NegativeTTL every miss should hit remote again")
+}
+
+func TestLayered_Read_negativeCachedWithTTL(t *testing.T) {
+	remote := NewCountingReader()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8, NegativeTTL: time.Minute})
+
+	_, err := c.Read("boo")
+	assert.NotNil(t, err)
+
+	_, err = c.Read("boo")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, remote.Reads(), "with NegativeTTL set the second miss should be served from L1")
+}
+
+func TestLayered_ReadMulti(t *testing.T) {
+	remote := NewCountingReader()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8})
+
+	keys := []string{"foo", "boo", "fox"}
+
+	m, err := c.ReadMulti(keys)
+	assert.Nil(t, err)
+	assert.Len(t, m, 2)
+	assert.Equal(t, []byte(`{"foo":"bar"}`), m["foo"])
+	assert.Equal(t, []byte(`{"fox":"baz"}`), m["fox"])
+
+	_, ok := m["boo"]
+	assert.False(t, ok)
+}
+
+func TestLayered_Write(t *testing.T) {
+	remote := NewCountingReader()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8})
+
+	err := c.Write("new", []byte("value"), time.Minute)
+	assert.Nil(t, err)
+
+	b, err := c.Read("new")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), b)
+	assert.Equal(t, 0, remote.Reads(), "Write should populate L1 so the following Read never hits remote")
+}
+
+func TestLayered_Invalidate(t *testing.T) {
+	remote := NewCountingReader()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8})
+
+	_, err := c.Read("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, remote.Reads())
+
+	err = c.Invalidate("foo")
+	assert.Nil(t, err)
+
+	_, err = c.Read("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, remote.Reads(), "Invalidate should evict L1 so the next read hits remote again")
+}
+
+func TestLayered_Invalidate_publishes(t *testing.T) {
+	remote := NewCountingReader()
+	pub := &recordingPublisher{}
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8, Publisher: pub})
+
+	err := c.Invalidate("foo", "fox")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "fox"}, pub.keys)
+}
+
+func TestLayered_metric(t *testing.T) {
+	remote := NewCountingReader()
+	m := NewLayeredMetric()
+	c := cache.NewLayered("cache/layered", remote, cache.LayeredOption{Size: 8, Metric: m})
+
+	_, err := c.Read("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, m.miss["L1"])
+	assert.Equal(t, 1, m.hits["L2"])
+
+	_, err = c.Read("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, m.hits["L1"])
+}
+
+type recordingPublisher struct {
+	keys []string
+}
+
+func (p *recordingPublisher) Publish(channel string, keys ...string) error {
+	p.keys = append(p.keys, keys...)
+	return nil
+}
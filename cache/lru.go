@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is a fixed-size, TTL-bounded LRU cache safe for concurrent use. It
+// backs Layered's local layer; entries may hold either a value or a
+// negative marker (a cached "not found").
+type lru struct {
+	mu       sync.Mutex
+	size     int
+	ttl      time.Duration
+	ll       *list.List
+	elements map[string]*list.Element
+}
+
+type lruEntry struct {
+	key      string
+	value    []byte
+	negative bool
+	expires  time.Time
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &lru{
+		size:     size,
+		ttl:      ttl,
+		ll:       list.New(),
+		elements: make(map[string]*list.Element, size),
+	}
+}
+
+// Get reports whether key is present and not expired. miss is true when the
+// entry is a negative marker, in which case value is meaningless.
+func (c *lru) Get(key string) (value []byte, miss bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.elements[key]
+	if !found {
+		return nil, false, false
+	}
+
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.removeElement(el)
+		return nil, false, false
+	}
+
+	c.ll.MoveToFront(el)
+
+	return e.value, e.negative, true
+}
+
+// Set stores value under key, evicting the least recently used entry if the
+// cache is at capacity.
+func (c *lru) Set(key string, value []byte, negative bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	c.set(key, value, negative, expires)
+}
+
+// SetNegative caches a "not found" for key for the given ttl.
+func (c *lru) SetNegative(key string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, nil, true, time.Now().Add(ttl))
+}
+
+func (c *lru) set(key string, value []byte, negative bool, expires time.Time) {
+	if el, ok := c.elements[key]; ok {
+		c.ll.MoveToFront(el)
+		el.Value.(*lruEntry).value = value
+		el.Value.(*lruEntry).negative = negative
+		el.Value.(*lruEntry).expires = expires
+		return
+	}
+
+	el := c.ll.PushFront(&lruEntry{key: key, value: value, negative: negative, expires: expires})
+	c.elements[key] = el
+
+	if c.ll.Len() > c.size {
+		c.removeOldest()
+	}
+}
+
+// Del evicts key, if present.
+func (c *lru) Del(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.elements[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *lru) removeOldest() {
+	if el := c.ll.Back(); el != nil {
+		c.removeElement(el)
+	}
+}
+
+func (c *lru) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.elements, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,180 @@
+package redis
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	redisc "gopkg.in/redis.v3"
+)
+
+const (
+	subscribeMinBackoff = 100 * time.Millisecond
+	subscribeMaxBackoff = 30 * time.Second
+)
+
+// Invalidation is broadcast over a channel by Publish whenever a node
+// evicts keys, so peers subscribed to the same channel can purge them from
+// their own local cache.
+type Invalidation struct {
+	Keys   []string  `json:"keys"`
+	Source string    `json:"source"`
+	At     time.Time `json:"at"`
+}
+
+// Publish broadcasts an Invalidation for keys on channel. Messages are
+// length-prefixed JSON so future fields can be added without breaking
+// older subscribers. Source is this process's hostname and pid followed by
+// a per-instance sequence number, letting Subscribe filter out this
+// instance's own publishes while still delivering those of other instances,
+// including ones in the same process.
+func (r *Redis) Publish(channel string, keys ...string) error {
+	msg, err := encodeInvalidation(Invalidation{
+		Keys:   keys,
+		Source: r.source,
+		At:     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = r.observe("Publish", func() error {
+		return r.pubsub().Publish(channel, msg).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.opt.Metric != nil {
+		r.opt.Metric.Invalidation(r.Name(), "sent")
+	}
+
+	return nil
+}
+
+// Subscribe listens on channel for Invalidation messages published by
+// peers, filtering out this instance's own publishes. The connection
+// reconnects automatically with exponential backoff on failure; reconnect
+// attempts and any other subscription errors are surfaced on Errors()
+// rather than closing the returned channel.
+func (r *Redis) Subscribe(channel string) (<-chan Invalidation, error) {
+	pubsub, err := r.pubsub().Subscribe(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Invalidation)
+
+	go r.subscribeLoop(channel, pubsub, out)
+
+	return out, nil
+}
+
+// Errors surfaces reconnect attempts and other errors encountered while
+// servicing Subscribe. It is shared across every channel this Redis
+// instance subscribes to.
+func (r *Redis) Errors() <-chan error {
+	return r.errs
+}
+
+func (r *Redis) subscribeLoop(channel string, pubsub *redisc.PubSub, out chan<- Invalidation) {
+	backoff := subscribeMinBackoff
+
+	for {
+		msg, err := pubsub.ReceiveMessage()
+		if err != nil {
+			r.sendErr(err)
+			pubsub.Close()
+
+			time.Sleep(backoff)
+
+			backoff *= 2
+			if backoff > subscribeMaxBackoff {
+				backoff = subscribeMaxBackoff
+			}
+
+			ps, err := r.pubsub().Subscribe(channel)
+			if err != nil {
+				r.sendErr(err)
+				continue
+			}
+
+			pubsub = ps
+			continue
+		}
+
+		backoff = subscribeMinBackoff
+
+		inv, err := decodeInvalidation(msg.Payload)
+		if err != nil {
+			r.sendErr(err)
+			continue
+		}
+
+		if inv.Source == r.source {
+			continue
+		}
+
+		if r.opt.Metric != nil {
+			r.opt.Metric.Invalidation(r.Name(), "received")
+		}
+
+		out <- inv
+	}
+}
+
+func (r *Redis) sendErr(err error) {
+	select {
+	case r.errs <- err:
+	default:
+	}
+}
+
+func encodeInvalidation(inv Invalidation) (string, error) {
+	b, err := json.Marshal(inv)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[4:], b)
+
+	return string(buf), nil
+}
+
+func decodeInvalidation(msg string) (Invalidation, error) {
+	if len(msg) < 4 {
+		return Invalidation{}, errors.New("redis: invalidation message too short")
+	}
+
+	n := binary.BigEndian.Uint32([]byte(msg[:4]))
+	if int(n) != len(msg)-4 {
+		return Invalidation{}, errors.New("redis: invalidation message length mismatch")
+	}
+
+	var inv Invalidation
+
+	if err := json.Unmarshal([]byte(msg[4:]), &inv); err != nil {
+		return Invalidation{}, err
+	}
+
+	return inv, nil
+}
+
+// sourceSeq numbers the Redis instances of this process, so each one gets a
+// distinct Source.
+var sourceSeq uint64
+
+func newSource() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), atomic.AddUint64(&sourceSeq, 1))
+}
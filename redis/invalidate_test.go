@@ -0,0 +1,38 @@
+package redis
+
+import "testing"
+
+func TestEncodeDecodeInvalidation(t *testing.T) {
+	want := Invalidation{Keys: []string{"foo", "fox"}, Source: "host:1"}
+
+	msg, err := encodeInvalidation(want)
+	if err != nil {
+		t.Fatalf("encodeInvalidation: %v", err)
+	}
+
+	got, err := decodeInvalidation(msg)
+	if err != nil {
+		t.Fatalf("decodeInvalidation: %v", err)
+	}
+
+	if got.Source != want.Source || len(got.Keys) != len(want.Keys) {
+		t.Fatalf("decodeInvalidation roundtrip mismatch: got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeInvalidation_tooShort(t *testing.T) {
+	if _, err := decodeInvalidation("ab"); err == nil {
+		t.Fatal("expected an error for a message shorter than the length prefix")
+	}
+}
+
+func TestDecodeInvalidation_lengthMismatch(t *testing.T) {
+	msg, err := encodeInvalidation(Invalidation{Keys: []string{"foo"}})
+	if err != nil {
+		t.Fatalf("encodeInvalidation: %v", err)
+	}
+
+	if _, err := decodeInvalidation(msg + "garbage"); err == nil {
+		t.Fatal("expected an error when the trailing payload doesn't match the length prefix")
+	}
+}
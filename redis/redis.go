@@ -0,0 +1,451 @@
+// Package redis wraps gopkg.in/redis.v3 with the cache.WriteReader contract
+// used across ottoman, transparently supporting both single-node and
+// cluster deployments behind the same API.
+package redis
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	redisc "gopkg.in/redis.v3"
+)
+
+// Metric is implemented by callers that want visibility into the latency of
+// every command issued by Redis. Implementations must be safe for
+// concurrent use.
+type Metric interface {
+	// CacheLatency reports how long action took. outcome is "ok", "error",
+	// or "cancelled" (a *Context call abandoned because its context was
+	// done before the command returned).
+	CacheLatency(name, action, outcome string, n time.Duration)
+
+	// Invalidation counts pub/sub cache invalidations sent or received by
+	// Publish/Subscribe. direction is "sent" or "received".
+	Invalidation(name, direction string)
+}
+
+// MultiSlotStrategy controls how ReadMulti behaves in cluster mode when the
+// requested keys don't all share a hash slot.
+type MultiSlotStrategy int
+
+const (
+	// MultiSlotError surfaces the cluster's CROSSSLOT error as-is. This is
+	// the default, matching today's behavior.
+	MultiSlotError MultiSlotStrategy = iota
+
+	// MultiSlotPipeline groups keys by hash slot and issues one MGET per
+	// slot concurrently, merging the results instead of failing.
+	MultiSlotPipeline
+)
+
+// Option configures a Redis client. Addrs holding a single address connects
+// in single-node mode; more than one switches to cluster mode.
+//
+// gopkg.in/redis.v3 predates context.Context support, so the *Context
+// methods (ReadContext, ReadMultiContext, IncrContext, ExpireContext)
+// approximate cancellation by racing the command against ctx.Done() on a
+// connection reserved for cancellable calls and shared across them, kept
+// separate from the connection plain Read/Write/Incr/Expire calls use; if
+// the context wins, that connection is discarded and replaced so it stops
+// occupying every later cancellable call, without disturbing non-cancellable
+// callers or paying the cost of a fresh connection on every call. This is a
+// stepping stone: migrating to go-redis/v8, which is context-native, would
+// let cancellation abort the in-flight command directly instead of
+// discarding the connection it was issued on, and is the intended next step
+// for this package.
+type Option struct {
+	Addrs  []string
+	DB     int64
+	Metric Metric
+
+	// MultiSlotStrategy picks how ReadMulti handles keys spread across
+	// multiple hash slots in cluster mode. It has no effect in single-node
+	// mode.
+	MultiSlotStrategy MultiSlotStrategy
+
+	// MultiSlotConcurrency caps how many per-slot MGETs run at once when
+	// MultiSlotStrategy is MultiSlotPipeline. It defaults to
+	// runtime.NumCPU().
+	MultiSlotConcurrency int
+}
+
+// connector is the subset of gopkg.in/redis.v3's client surface that Redis
+// relies on for ordinary commands. Both *redisc.Client and
+// *redisc.ClusterClient satisfy it, which is what lets Redis treat
+// single-node and cluster deployments uniformly. Publish/Subscribe aren't
+// part of it: *redisc.ClusterClient doesn't implement them, since a cluster
+// node broadcasts PUBLISH cluster-wide regardless of which node receives
+// it, so Redis talks to a dedicated single-node client for those instead
+// (see pubsubConn).
+type connector interface {
+	Get(key string) *redisc.StringCmd
+	MGet(keys ...string) *redisc.SliceCmd
+	Incr(key string) *redisc.IntCmd
+	Expire(key string, expiration time.Duration) *redisc.BoolCmd
+	Del(keys ...string) *redisc.IntCmd
+	Set(key string, value interface{}, expiration time.Duration) *redisc.StatusCmd
+	TTL(key string) *redisc.DurationCmd
+	Eval(script string, keys []string, args []string) *redisc.Cmd
+	EvalSha(sha1 string, keys []string, args []string) *redisc.Cmd
+	Close() error
+}
+
+// Redis is a cache.WriteReader backed by Redis, single-node or cluster.
+type Redis struct {
+	opt     *Option
+	cluster bool
+	source  string
+	errs    chan error
+
+	conn connector
+
+	ctxConnMu sync.RWMutex
+	ctxConn   connector
+
+	pubsubConn *redisc.Client
+}
+
+// New creates a Redis client from opt. Cluster mode is selected
+// automatically whenever opt.Addrs holds more than one address.
+func New(opt *Option) *Redis {
+	r := &Redis{
+		opt:     opt,
+		cluster: len(opt.Addrs) > 1,
+		source:  newSource(),
+		errs:    make(chan error, 16),
+	}
+
+	r.conn = newConnector(opt, r.cluster)
+	r.ctxConn = newConnector(opt, r.cluster)
+	r.pubsubConn = newSingleConnector(opt)
+
+	return r
+}
+
+func newConnector(opt *Option, cluster bool) connector {
+	if cluster {
+		return redisc.NewClusterClient(&redisc.ClusterOptions{
+			Addrs: opt.Addrs,
+		})
+	}
+
+	return newSingleConnector(opt)
+}
+
+func newSingleConnector(opt *Option) *redisc.Client {
+	addr := ""
+	if len(opt.Addrs) > 0 {
+		addr = opt.Addrs[0]
+	}
+
+	return redisc.NewClient(&redisc.Options{
+		Addr: addr,
+		DB:   opt.DB,
+	})
+}
+
+// Name identifies this backend for metrics and logging.
+func (r *Redis) Name() string {
+	if r.cluster {
+		return "Redis Cluster"
+	}
+
+	return "Redis"
+}
+
+// client returns the long-lived connector shared by every non-cancellable
+// call. It never changes after New, so it needs no locking.
+func (r *Redis) client() connector {
+	return r.conn
+}
+
+// ctxClient returns the connector shared by cancellable *Context calls,
+// kept separate from client() so that discardCtxConn, run after one of
+// them is abandoned, can never disrupt a plain Read/Write/Incr/Expire.
+func (r *Redis) ctxClient() connector {
+	r.ctxConnMu.RLock()
+	defer r.ctxConnMu.RUnlock()
+
+	return r.ctxConn
+}
+
+// discardCtxConn replaces the connector cancellable calls share after a
+// command on it was abandoned, so the abandoned command doesn't keep
+// occupying every later cancellable call's connection.
+func (r *Redis) discardCtxConn() {
+	r.ctxConnMu.Lock()
+	defer r.ctxConnMu.Unlock()
+
+	r.ctxConn.Close()
+	r.ctxConn = newConnector(r.opt, r.cluster)
+}
+
+// pubsub returns the dedicated single-node client Publish and Subscribe use.
+// It never changes after New, so it needs no locking.
+func (r *Redis) pubsub() *redisc.Client {
+	return r.pubsubConn
+}
+
+// Write stores value under key with the given expiration.
+func (r *Redis) Write(key string, value []byte, expiration time.Duration) error {
+	return r.observe("Set", func() error {
+		return r.client().Set(key, value, expiration).Err()
+	})
+}
+
+// Read returns the value stored under key, or an error if it is absent.
+// It is a thin wrapper around ReadContext using context.Background().
+func (r *Redis) Read(key string) ([]byte, error) {
+	return r.ReadContext(context.Background(), key)
+}
+
+// ReadContext is Read honoring ctx: if ctx is done before the command
+// returns, the connection it was issued on is abandoned and ctx.Err() is
+// returned.
+func (r *Redis) ReadContext(ctx context.Context, key string) ([]byte, error) {
+	v, err := r.observeContext(ctx, "Get", func(c connector) (interface{}, error) {
+		return c.Get(key).Bytes()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]byte), nil
+}
+
+// ReadMulti returns the values stored under keys. Keys with no value are
+// omitted from the returned map rather than present with a nil value.
+//
+// In cluster mode, keys spread across multiple hash slots normally fail
+// with CROSSSLOT; setting Option.MultiSlotStrategy to MultiSlotPipeline
+// instead fans the read out per slot and merges the results. It is a thin
+// wrapper around ReadMultiContext using context.Background().
+func (r *Redis) ReadMulti(keys []string) (map[string][]byte, error) {
+	return r.ReadMultiContext(context.Background(), keys)
+}
+
+// ReadMultiContext is ReadMulti honoring ctx: if ctx is done before the
+// command (or, in pipelined cluster mode, the whole fan-out) returns, the
+// connection it was issued on is abandoned and ctx.Err() is returned.
+func (r *Redis) ReadMultiContext(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if r.cluster && r.opt.MultiSlotStrategy == MultiSlotPipeline {
+		return r.readMultiPipelinedContext(ctx, keys)
+	}
+
+	v, err := r.observeContext(ctx, "MGet", func(c connector) (interface{}, error) {
+		vs, err := c.MGet(keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		return readMultiResult(keys, vs), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string][]byte), nil
+}
+
+// readMultiPipelinedContext groups keys by hash slot and issues one MGET
+// per slot concurrently, bounded by Option.MultiSlotConcurrency. It
+// propagates the first non-nil per-slot error while still returning values
+// collected from slots that succeeded, and times the whole fan-out as a
+// single "MGet" sample rather than one per slot.
+func (r *Redis) readMultiPipelinedContext(ctx context.Context, keys []string) (map[string][]byte, error) {
+	bySlot := make(map[uint16][]string)
+	for _, key := range keys {
+		slot := hashSlot(key)
+		bySlot[slot] = append(bySlot[slot], key)
+	}
+
+	slots := make([][]string, 0, len(bySlot))
+	for _, ks := range bySlot {
+		slots = append(slots, ks)
+	}
+
+	concurrency := r.opt.MultiSlotConcurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	results := make([]map[string][]byte, len(slots))
+	errs := make([]error, len(slots))
+
+	v, err := r.observeContext(ctx, "MGet", func(c connector) (interface{}, error) {
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+
+		for i, ks := range slots {
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func(i int, ks []string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				vs, err := c.MGet(ks...).Result()
+				if err != nil {
+					errs[i] = err
+					return
+				}
+
+				results[i] = readMultiResult(ks, vs)
+			}(i, ks)
+		}
+
+		wg.Wait()
+
+		z := make(map[string][]byte, len(keys))
+		for _, res := range results {
+			for k, val := range res {
+				z[k] = val
+			}
+		}
+
+		for _, err := range errs {
+			if err != nil {
+				return z, err
+			}
+		}
+
+		return z, nil
+	})
+
+	if v == nil {
+		return nil, err
+	}
+
+	return v.(map[string][]byte), err
+}
+
+func readMultiResult(keys []string, vs []interface{}) map[string][]byte {
+	z := make(map[string][]byte, len(keys))
+
+	for i, v := range vs {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		z[keys[i]] = []byte(s)
+	}
+
+	return z
+}
+
+// Incr increments the integer value stored under key and returns the
+// result. It is a thin wrapper around IncrContext using
+// context.Background().
+func (r *Redis) Incr(key string) (int64, error) {
+	return r.IncrContext(context.Background(), key)
+}
+
+// IncrContext is Incr honoring ctx: if ctx is done before the command
+// returns, the connection it was issued on is abandoned and ctx.Err() is
+// returned.
+func (r *Redis) IncrContext(ctx context.Context, key string) (int64, error) {
+	v, err := r.observeContext(ctx, "Incr", func(c connector) (interface{}, error) {
+		return c.Incr(key).Result()
+	})
+	if v == nil {
+		return 0, err
+	}
+
+	return v.(int64), err
+}
+
+// Expire sets a new TTL on key, reporting whether the key existed. It is a
+// thin wrapper around ExpireContext using context.Background().
+func (r *Redis) Expire(key string, expiration time.Duration) (bool, error) {
+	return r.ExpireContext(context.Background(), key, expiration)
+}
+
+// ExpireContext is Expire honoring ctx: if ctx is done before the command
+// returns, the connection it was issued on is abandoned and ctx.Err() is
+// returned.
+func (r *Redis) ExpireContext(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	v, err := r.observeContext(ctx, "Expire", func(c connector) (interface{}, error) {
+		return c.Expire(key, expiration).Result()
+	})
+	if v == nil {
+		return false, err
+	}
+
+	return v.(bool), err
+}
+
+func (r *Redis) observe(action string, fn func() error) error {
+	t := time.Now()
+	err := fn()
+
+	if r.opt.Metric != nil {
+		r.opt.Metric.CacheLatency(r.Name(), action, outcomeFor(err), time.Since(t))
+	}
+
+	return err
+}
+
+// observeContext runs fn against a connector and, if ctx can be cancelled,
+// races it against ctx.Done(). A ctx.Done() that is nil (context.Background
+// or context.TODO, as used by Read, ReadMulti, Incr and Expire) can never
+// fire, so fn runs straight against the shared client with no extra
+// overhead. Otherwise fn runs against ctxClient(), the connector cancellable
+// calls share: if ctx wins the race, discardCtxConn abandons the command by
+// replacing that connector (gopkg.in/redis.v3 has no native cancellation,
+// see Option's godoc) without touching the client non-context callers use,
+// and (nil, ctx.Err()) is returned; fn's own result, if it arrives later, is
+// discarded. Either way a single CacheLatency sample covering the whole
+// call is recorded, with outcome "ok", "error" or "cancelled".
+func (r *Redis) observeContext(ctx context.Context, action string, fn func(connector) (interface{}, error)) (interface{}, error) {
+	t := time.Now()
+
+	if ctx.Done() == nil {
+		v, err := fn(r.client())
+
+		if r.opt.Metric != nil {
+			r.opt.Metric.CacheLatency(r.Name(), action, outcomeFor(err), time.Since(t))
+		}
+
+		return v, err
+	}
+
+	type result struct {
+		v   interface{}
+		err error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		v, err := fn(r.ctxClient())
+		done <- result{v, err}
+	}()
+
+	select {
+	case res := <-done:
+		if r.opt.Metric != nil {
+			r.opt.Metric.CacheLatency(r.Name(), action, outcomeFor(res.err), time.Since(t))
+		}
+
+		return res.v, res.err
+	case <-ctx.Done():
+		r.discardCtxConn()
+
+		if r.opt.Metric != nil {
+			r.opt.Metric.CacheLatency(r.Name(), action, "cancelled", time.Since(t))
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+func outcomeFor(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
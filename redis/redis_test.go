@@ -1,6 +1,8 @@
 package redis_test
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -66,8 +68,9 @@ func (suite *CommonSuite) ReadMetric(name string) {
 	assert.Len(suite.T(), mc, 1)
 
 	labels := map[string]string{
-		"name":   name,
-		"action": "Get",
+		"name":    name,
+		"action":  "Get",
+		"outcome": "ok",
 	}
 
 	for _, m := range mc {
@@ -131,8 +134,9 @@ func (suite *CommonSuite) ReadMultiMetric(name string) {
 	assert.Len(suite.T(), mc, 1)
 
 	labels := map[string]string{
-		"name":   name,
-		"action": "MGet",
+		"name":    name,
+		"action":  "MGet",
+		"outcome": "ok",
 	}
 
 	for _, m := range mc {
@@ -145,6 +149,83 @@ func (suite *CommonSuite) ReadMultiMetric(name string) {
 	}
 }
 
+func (suite *CommonSuite) TestReadContext_cancelled() {
+	suite.loadFixtures()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	b, err := suite.c.ReadContext(ctx, "foo")
+	assert.Equal(suite.T(), context.Canceled, err)
+	assert.Nil(suite.T(), b)
+}
+
+func (suite *CommonSuite) ReadContextMetric_cancelled(name string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := suite.cm.ReadContext(ctx, "foo")
+	assert.Equal(suite.T(), context.Canceled, err)
+
+	mc, err := suite.m.Gather("cache_latency_seconds")
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), mc, 1)
+
+	labels := map[string]string{
+		"name":    name,
+		"action":  "Get",
+		"outcome": "cancelled",
+	}
+
+	for _, m := range mc {
+		for _, label := range m.GetLabel() {
+			assert.Equal(suite.T(), labels[label.GetName()], label.GetValue())
+		}
+	}
+}
+
+func (suite *CommonSuite) TestSetNX_lockAndRelease() {
+	suite.client.Del("lock:foo")
+	defer suite.client.Del("lock:foo")
+
+	token, err := suite.c.SetNX("lock:foo", time.Minute)
+	assert.Nil(suite.T(), err)
+	assert.NotEmpty(suite.T(), token)
+
+	_, err = suite.c.SetNX("lock:foo", time.Minute)
+	assert.Equal(suite.T(), redis.ErrLockHeld, err)
+
+	ok, err := suite.c.CompareAndDelete("lock:foo", "wrong-token")
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), ok)
+
+	ok, err = suite.c.CompareAndDelete("lock:foo", token)
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), ok)
+
+	token, err = suite.c.SetNX("lock:foo", time.Minute)
+	assert.Nil(suite.T(), err)
+	assert.NotEmpty(suite.T(), token)
+}
+
+func (suite *CommonSuite) TestIncrWithCap() {
+	suite.client.Del("counter:foo")
+	defer suite.client.Del("counter:foo")
+
+	for i := int64(1); i <= 3; i++ {
+		n, err := suite.c.IncrWithCap("counter:foo", 3, time.Minute)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), i, n)
+	}
+
+	_, err := suite.c.IncrWithCap("counter:foo", 3, time.Minute)
+	assert.Equal(suite.T(), redis.ErrCapExceeded, err)
+
+	n, err := suite.client.Get("counter:foo").Int64()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), n, "a capped increment must roll itself back")
+}
+
 func (suite *CommonSuite) TestIncr() {
 	n, err := suite.c.Incr("foo")
 	assert.Nil(suite.T(), err)
@@ -164,6 +245,26 @@ func (suite *CommonSuite) TestExpire() {
 	assert.Equal(suite.T(), time.Hour, cmd.Val())
 }
 
+func (suite *CommonSuite) PubSubRoundtrip(opt *redis.Option) {
+	a := redis.New(opt)
+	b := redis.New(opt)
+
+	ch, err := b.Subscribe("cache/invalidate")
+	assert.Nil(suite.T(), err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Nil(suite.T(), a.Publish("cache/invalidate", "foo", "fox"))
+
+	select {
+	case inv := <-ch:
+		assert.Equal(suite.T(), []string{"foo", "fox"}, inv.Keys)
+		assert.NotEmpty(suite.T(), inv.Source)
+	case <-time.After(2 * time.Second):
+		suite.T().Fatal("timed out waiting for invalidation")
+	}
+}
+
 func (suite *CommonSuite) loadFixtures() {
 	data := map[string]string{
 		"foo":     `{"foo":"bar"}`,
@@ -216,6 +317,17 @@ func (suite *RedisSuite) TestReadMulti_metric() {
 	suite.ReadMultiMetric("Redis")
 }
 
+func (suite *RedisSuite) TestReadContext_metric_cancelled() {
+	suite.ReadContextMetric_cancelled("Redis")
+}
+
+func (suite *RedisSuite) TestSubscribe_invalidation() {
+	suite.PubSubRoundtrip(&redis.Option{
+		Addrs: []string{os.Getenv("REDIS_ADDR")},
+		DB:    int64(envx.Int("REDIS_DB")),
+	})
+}
+
 func TestRedisSuite(t *testing.T) {
 	suite.Run(t, &RedisSuite{new(CommonSuite)})
 }
@@ -254,6 +366,10 @@ func (suite *RedisClusterSuite) TestReadMulti_metric() {
 	suite.ReadMultiMetric("Redis Cluster")
 }
 
+func (suite *RedisClusterSuite) TestReadContext_metric_cancelled() {
+	suite.ReadContextMetric_cancelled("Redis Cluster")
+}
+
 func (suite *RedisClusterSuite) TestReadMulti_CROSSSLOT() {
 	suite.loadFixtures()
 
@@ -267,6 +383,42 @@ func (suite *RedisClusterSuite) TestReadMulti_CROSSSLOT() {
 	assert.Nil(suite.T(), m)
 }
 
+func (suite *RedisClusterSuite) TestReadMulti_pipelined() {
+	addrs := strings.Split(os.Getenv("REDIS_CLUSTER_ADDR"), ",")
+
+	keys := make([]string, 0, 12)
+	data := make(map[string]string, 12)
+
+	for i := 0; i < 12; i++ {
+		key := fmt.Sprintf("slot-%d", i)
+		keys = append(keys, key)
+		data[key] = fmt.Sprintf(`{"i":%d}`, i)
+	}
+
+	for k, v := range data {
+		assert.Nil(suite.T(), suite.client.Set(k, v, time.Minute).Err())
+	}
+
+	c := redis.New(&redis.Option{
+		Addrs:             addrs,
+		MultiSlotStrategy: redis.MultiSlotPipeline,
+	})
+
+	m, err := c.ReadMulti(keys)
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), m, 12)
+
+	for k, v := range data {
+		assert.Equal(suite.T(), []byte(v), m[k])
+	}
+}
+
+func (suite *RedisClusterSuite) TestSubscribe_invalidation() {
+	suite.PubSubRoundtrip(&redis.Option{
+		Addrs: strings.Split(os.Getenv("REDIS_CLUSTER_ADDR"), ","),
+	})
+}
+
 func TestRedisClusterSuite(t *testing.T) {
 	suite.Run(t, &RedisClusterSuite{new(CommonSuite)})
 }
@@ -282,7 +434,7 @@ func NewMetric() *Metric {
 	m.cacheLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "cache_latency_seconds",
 		Help: "A histogram of the cache latency in seconds.",
-	}, []string{"name", "action"})
+	}, []string{"name", "action", "outcome"})
 
 	m.registry.MustRegister(m.cacheLatency)
 
@@ -306,6 +458,8 @@ func (m *Metric) Gather(name string) ([]*dto.Metric, error) {
 	return nil, err
 }
 
-func (m *Metric) CacheLatency(name, action string, n time.Duration) {
-	m.cacheLatency.With(prometheus.Labels{"name": name, "action": action}).Observe(n.Seconds())
+func (m *Metric) CacheLatency(name, action, outcome string, n time.Duration) {
+	m.cacheLatency.With(prometheus.Labels{"name": name, "action": action, "outcome": outcome}).Observe(n.Seconds())
 }
+
+func (m *Metric) Invalidation(name, direction string) {}
@@ -0,0 +1,242 @@
+package redis
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	redisc "gopkg.in/redis.v3"
+)
+
+// errScriptCrossSlot is returned by BoundScript.Run in cluster mode when
+// the given keys don't all share a hash slot. Unlike a plain multi-key
+// command, which would surface the node's raw CROSSSLOT error, this is
+// reported up-front since a script spanning slots can never be routed
+// correctly.
+var errScriptCrossSlot = errors.New("redis: script keys must share a single hash slot")
+
+// Script wraps a Lua source for atomic execution against Redis, mirroring
+// the EVALSHA-with-fallback pattern from gopkg.in/redis.v2's script.go: Run
+// tries EVALSHA using the precomputed SHA1 of src first, and falls back to
+// EVAL on NOSCRIPT, which also primes the server's script cache for
+// subsequent EVALSHA calls. Bind it to a client with Redis.Script before
+// calling Run.
+type Script struct {
+	src  string
+	hash string
+}
+
+// NewScript registers src for later execution via Run. It performs no I/O;
+// the SHA1 used for EVALSHA is computed locally.
+func NewScript(src string) *Script {
+	h := sha1.Sum([]byte(src))
+
+	return &Script{src: src, hash: hex.EncodeToString(h[:])}
+}
+
+// BoundScript binds a Script to the Redis client it runs against, returned
+// by Redis.Script so Run's signature stays exactly Run(keys, args...)
+// without callers having to thread a client through every call.
+type BoundScript struct {
+	r *Redis
+	s *Script
+}
+
+// Script binds s to r, returning a runner scoped to this client.
+func (r *Redis) Script(s *Script) *BoundScript {
+	return &BoundScript{r: r, s: s}
+}
+
+// Run executes the bound script with the given keys and args. In cluster
+// mode every key must share a hash slot; Run returns errScriptCrossSlot
+// rather than letting the node reply CROSSSLOT. gopkg.in/redis.v3's
+// Eval/EvalSha take args as []string, so args is formatted to strings
+// before being sent, exactly as the Lua ARGV table expects.
+func (bs *BoundScript) Run(keys []string, args ...interface{}) (interface{}, error) {
+	r, s := bs.r, bs.s
+
+	if r.cluster && !sameSlot(keys) {
+		return nil, errScriptCrossSlot
+	}
+
+	sargs := formatArgs(args)
+
+	v, err := r.client().EvalSha(s.hash, keys, sargs).Result()
+	if err != nil && isNoScript(err) {
+		v, err = r.client().Eval(s.src, keys, sargs).Result()
+	}
+
+	return v, err
+}
+
+// formatArgs renders args the way EVAL/EVALSHA expect to receive them: as
+// the literal strings Lua's ARGV table will hold, so tonumber(ARGV[n])
+// sees the plain decimal instead of e.g. a Go %v rendering of a typed int.
+func formatArgs(args []interface{}) []string {
+	z := make([]string, len(args))
+
+	for i, a := range args {
+		switch v := a.(type) {
+		case string:
+			z[i] = v
+		case int64:
+			z[i] = strconv.FormatInt(v, 10)
+		default:
+			z[i] = fmt.Sprint(v)
+		}
+	}
+
+	return z
+}
+
+func isNoScript(err error) bool {
+	return strings.Contains(err.Error(), "NOSCRIPT")
+}
+
+func sameSlot(keys []string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+
+	slot := hashSlot(keys[0])
+
+	for _, key := range keys[1:] {
+		if hashSlot(key) != slot {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scriptSetNX backs SetNX: it sets key to token with a TTL only if key is
+// absent, returning the token on success or false if the key was already
+// held, so the same Lua call doubles as both the write and the result
+// check.
+var scriptSetNX = NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return ARGV[1]
+end
+return false
+`)
+
+// scriptCAD backs CompareAndDelete: it deletes key only if its current
+// value still matches the expected one, returning whether it did.
+var scriptCAD = NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// scriptIncrCap backs IncrWithCap: it increments key, arming ttl on the
+// first increment, then rolls the increment back and reports the cap was
+// hit if the result exceeds max.
+var scriptIncrCap = NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 and tonumber(ARGV[2]) > 0 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+if n > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[1])
+	return -1
+end
+return n
+`)
+
+// SetNX acquires a distributed lock on key for ttl, returning a random
+// token identifying this holder on success. A held lock reports
+// ErrLockHeld. Release it with CompareAndDelete(key, token) so a holder
+// never deletes a lock it doesn't own.
+func (r *Redis) SetNX(key string, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	v, err := r.observeValue("EvalSetNX", func() (interface{}, error) {
+		return r.Script(scriptSetNX).Run([]string{key}, token, int64(ttl/time.Millisecond))
+	})
+	if err == redisc.Nil {
+		return "", ErrLockHeld
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if v == nil {
+		return "", ErrLockHeld
+	}
+
+	return token, nil
+}
+
+// CompareAndDelete deletes key only if its current value equals expected,
+// reporting whether it did. It's the safe way to release a SetNX lock.
+func (r *Redis) CompareAndDelete(key, expected string) (bool, error) {
+	v, err := r.observeValue("EvalCAD", func() (interface{}, error) {
+		return r.Script(scriptCAD).Run([]string{key}, expected)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	n, _ := v.(int64)
+
+	return n > 0, nil
+}
+
+// IncrWithCap atomically increments key, arming ttl the first time it's
+// set, and reports ErrCapExceeded instead of incrementing past max. This
+// is the atomic counterpart to a plain Incr+Expire, which can't express a
+// cap without a race between the read and the following Expire/Decr.
+func (r *Redis) IncrWithCap(key string, max int64, ttl time.Duration) (int64, error) {
+	v, err := r.observeValue("EvalIncrCap", func() (interface{}, error) {
+		return r.Script(scriptIncrCap).Run([]string{key}, max, int64(ttl/time.Second))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n, _ := v.(int64)
+	if n == -1 {
+		return 0, ErrCapExceeded
+	}
+
+	return n, nil
+}
+
+func (r *Redis) observeValue(action string, fn func() (interface{}, error)) (interface{}, error) {
+	t := time.Now()
+	v, err := fn()
+
+	if r.opt.Metric != nil {
+		r.opt.Metric.CacheLatency(r.Name(), action, outcomeFor(err), time.Since(t))
+	}
+
+	return v, err
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+var (
+	// ErrLockHeld is returned by SetNX when key is already locked.
+	ErrLockHeld = errors.New("redis: lock already held")
+
+	// ErrCapExceeded is returned by IncrWithCap when incrementing would
+	// push the counter past max; the counter is left unchanged.
+	ErrCapExceeded = errors.New("redis: counter cap exceeded")
+)
@@ -0,0 +1,32 @@
+package redis
+
+import "testing"
+
+func TestScriptRun_crossSlotRejected(t *testing.T) {
+	r := New(&Option{Addrs: []string{"127.0.0.1:7000", "127.0.0.1:7001"}})
+
+	script := NewScript(`return 1`)
+
+	_, err := r.Script(script).Run([]string{"foo", "{x}.fox"})
+	if err != errScriptCrossSlot {
+		t.Fatalf("Run() error = %v, want errScriptCrossSlot", err)
+	}
+}
+
+func TestSameSlot(t *testing.T) {
+	cases := []struct {
+		keys []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"foo"}, true},
+		{[]string{"{x}.foo", "{x}.fox"}, true},
+		{[]string{"foo", "{x}.fox"}, false},
+	}
+
+	for _, c := range cases {
+		if got := sameSlot(c.keys); got != c.want {
+			t.Fatalf("sameSlot(%v) = %v, want %v", c.keys, got, c.want)
+		}
+	}
+}
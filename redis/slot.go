@@ -0,0 +1,39 @@
+package redis
+
+import "strings"
+
+const slotCount = 16384
+
+// hashSlot computes the Redis Cluster hash slot for key, per the cluster
+// spec: when key contains a "{hashtag}", only the substring between the
+// first '{' and the next '}' is hashed, provided it is non-empty; otherwise
+// the whole key is hashed.
+func hashSlot(key string) uint16 {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+
+	return crc16(key) % slotCount
+}
+
+// crc16 implements the CRC16/XMODEM variant Redis Cluster uses to derive
+// hash slots (cluster.c's crc16, polynomial 0x1021, initial value 0).
+func crc16(s string) uint16 {
+	var crc uint16
+
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
@@ -0,0 +1,30 @@
+package redis
+
+import "testing"
+
+func TestHashSlot_hashtag(t *testing.T) {
+	a := hashSlot("{x}.foo")
+	b := hashSlot("{x}.fox")
+	c := hashSlot("{x}.boo")
+
+	if a != b || b != c {
+		t.Fatalf("keys sharing a hashtag must land on the same slot, got %d %d %d", a, b, c)
+	}
+}
+
+func TestHashSlot_emptyHashtagHashesWholeKey(t *testing.T) {
+	a := hashSlot("{}foo")
+	b := hashSlot("{}bar")
+
+	if a == b {
+		t.Fatalf("an empty hashtag must not be extracted, got equal slots for distinct keys")
+	}
+}
+
+func TestHashSlot_bounded(t *testing.T) {
+	for _, key := range []string{"foo", "bar", "{x}.foo", ""} {
+		if slot := hashSlot(key); slot >= slotCount {
+			t.Fatalf("hashSlot(%q) = %d, want < %d", key, slot, slotCount)
+		}
+	}
+}